@@ -0,0 +1,96 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+// alnumGlypher resolves the runes MathDriver and AlphanumericDriver
+// produce (letters, digits, and a few arithmetic symbols) to glyphs. It
+// falls back to the built-in digit font for '0'-'9' so Driver-rendered
+// captchas stay visually consistent with plain digit ones.
+type alnumGlypher struct{}
+
+func (alnumGlypher) Glyph(r rune) *charMap {
+	if r >= '0' && r <= '9' {
+		return font[byte(r-'0')]
+	}
+	if g, ok := alphaFont[toUpperASCII(r)]; ok {
+		return g
+	}
+	return alphaFont['?']
+}
+
+func toUpperASCII(r rune) rune {
+	if r >= 'a' && r <= 'z' {
+		return r - ('a' - 'A')
+	}
+	return r
+}
+
+// alphaBitmaps holds each supported rune as 7 rows of 5 bits (MSB first),
+// a compact, widely-used format for small embedded bitmap fonts. Lowercase
+// letters fold to their uppercase glyph via toUpperASCII.
+var alphaBitmaps = map[rune][7]byte{
+	'A': {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x19, 0x15, 0x13, 0x11, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x0A, 0x04, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'+': {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'*': {0x00, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x00},
+	'=': {0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'?': {0x0E, 0x11, 0x02, 0x04, 0x04, 0x00, 0x04},
+}
+
+// alphaFont is alphaBitmaps rasterized to the package's fontWidth x
+// fontHeight glyph grid, the same dimensions the digit font uses, so both
+// can be drawn through the same drawDigit path.
+var alphaFont = buildAlphaFont()
+
+func buildAlphaFont() map[rune]*charMap {
+	out := make(map[rune]*charMap, len(alphaBitmaps))
+	for r, bm := range alphaBitmaps {
+		out[r] = rasterize5x7(bm)
+	}
+	return out
+}
+
+// rasterize5x7 scales a 5x7 bitmap up to a fontWidth x fontHeight charMap
+// by nearest-neighbor sampling. Unset pixels are left at their zero value,
+// which drawDigit treats as "not blackChar".
+func rasterize5x7(bm [7]byte) *charMap {
+	var cm charMap
+	for y := 0; y < fontHeight; y++ {
+		srcY := y * 7 / fontHeight
+		row := bm[srcY]
+		for x := 0; x < fontWidth; x++ {
+			srcX := x * 5 / fontWidth
+			if row&(1<<uint(4-srcX)) != 0 {
+				cm[y*fontWidth+x] = blackChar
+			}
+		}
+	}
+	return &cm
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import "strings"
+
+// defaultAlphanumericCharset omits characters that are easily confused at
+// small sizes (0/O, 1/I/l, etc.).
+const defaultAlphanumericCharset = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+
+// AlphanumericDriver generates challenges drawn from a user-supplied
+// charset and rendered with the bundled alphanumeric bitmap font (see
+// alphafont.go), rather than being limited to the ten digit glyphs
+// NewImage draws from. alnumGlypher renders every letter with its
+// uppercase glyph regardless of the case Generate picked, so a solver
+// can't tell the original case from the image; Generate answers in
+// canonical uppercase, and VerifyString/NormalizeAnswer fold a submission
+// to the same case before comparing, so a solver is never required to
+// match case they were never shown. The zero value is ready to use.
+type AlphanumericDriver struct {
+	// Charset is the set of runes to draw from. Defaults to
+	// defaultAlphanumericCharset when empty.
+	Charset string
+	// Length is the number of characters in the generated challenge.
+	// Defaults to 6 when zero.
+	Length int
+	// IDLen is the length of generated ids. Defaults to 20 when zero.
+	IDLen int
+}
+
+func (d *AlphanumericDriver) charset() string {
+	if d.Charset == "" {
+		return defaultAlphanumericCharset
+	}
+	return d.Charset
+}
+
+func (d *AlphanumericDriver) length() int {
+	if d.Length == 0 {
+		return 6
+	}
+	return d.Length
+}
+
+func (d *AlphanumericDriver) idLen() int {
+	if d.IDLen == 0 {
+		return defaultDriverIDLen
+	}
+	return d.IDLen
+}
+
+// Generate implements Driver.
+func (d *AlphanumericDriver) Generate() (id, answer string, challenge []byte) {
+	id, answer, challenge = d.generate()
+	registerChallenge(id, answer, d)
+	return id, answer, challenge
+}
+
+// GenerateStateless implements StatelessGenerator, producing the same kind
+// of challenge as Generate without registering it in the package-level
+// challenges map.
+func (d *AlphanumericDriver) GenerateStateless() (id, answer string, challenge []byte) {
+	return d.generate()
+}
+
+func (d *AlphanumericDriver) generate() (id, answer string, challenge []byte) {
+	cs := d.charset()
+	b := make([]byte, d.length())
+	for i := range b {
+		b[i] = cs[randIntn(len(cs))]
+	}
+
+	id = randomID(d.idLen())
+	answer = strings.ToUpper(string(b))
+	return id, answer, b
+}
+
+// DrawImage implements Driver.
+func (d *AlphanumericDriver) DrawImage(id string, challenge []byte, width, height int, opts *DistortionOpts) *Image {
+	runes := []rune(string(challenge))
+	return newImage(id, challenge, runes, alnumGlypher{}, width, height, opts)
+}
+
+// NormalizeAnswer implements AnswerNormalizer, canonicalizing raw to the
+// uppercase form Generate's answer is already in, so Captcha.VerifyAnswer
+// can match a submission regardless of letter case.
+func (d *AlphanumericDriver) NormalizeAnswer(raw string) (normalized string, ok bool) {
+	return strings.ToUpper(raw), true
+}
+
+// VerifyString implements StringVerifier, comparing answer to expected
+// case-insensitively. alnumGlypher (see alphafont.go) renders every letter
+// with its uppercase glyph, so the rendered image never reveals which case
+// Generate actually picked.
+func (d *AlphanumericDriver) VerifyString(expected, answer string) bool {
+	return strings.EqualFold(expected, answer)
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// distortPaletted returns a new image built by applying shear and then
+// resampling src through a sine warp of the given amplitude and period,
+// offset by phase, using filter to reconstruct pixels that fall between
+// source samples. phase 0 and the zero Shear reproduce the original
+// per-image distortion exactly; Frames uses distinct phases to render an
+// animated captcha's frames.
+//
+// FilterNearest samples directly off the paletted source, same as distort
+// has always done. FilterBilinear and FilterCatmullRom instead resample an
+// intermediate RGBA buffer, which avoids the blocky aliasing truncation
+// produces and lets the warp be pushed harder before digits become
+// unreadable; the result is quantized back to src's palette so the caller
+// can keep drawing circles and strike-throughs directly on the paletted
+// output afterward.
+func distortPaletted(src *image.Paletted, amplitude, period, phase float64, filter Filter, shear Shear) *image.Paletted {
+	w := src.Bounds().Max.X
+	h := src.Bounds().Max.Y
+	dst := image.NewPaletted(image.Rect(0, 0, w, h), src.Palette)
+	dx := 2.0 * math.Pi / period
+
+	if filter == FilterNearest {
+		for x := 0; x < w; x++ {
+			for y := 0; y < h; y++ {
+				fx, fy, xo, yo := warpedOffset(x, y, amplitude, dx, phase, shear)
+				dst.SetColorIndex(x, y, src.ColorIndexAt(int(fx)+int(xo), int(fy)+int(yo)))
+			}
+		}
+		return dst
+	}
+
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, src.Bounds(), src, image.Point{}, draw.Src)
+
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			fx, fy, xo, yo := warpedOffset(x, y, amplitude, dx, phase, shear)
+			c := sampleRGBA(rgba, fx+xo, fy+yo, filter)
+			dst.SetColorIndex(x, y, uint8(src.Palette.Index(c)))
+		}
+	}
+	return dst
+}
+
+// warpedOffset computes the sheared destination coordinates (fx, fy) for
+// pixel (x, y) and the sine-warp offset (xo, yo) to resample from. Callers
+// combine these differently: FilterNearest truncates fx and xo (then fy and
+// yo) separately before adding, matching how the original, pre-Filter
+// distort truncated amplitude*sin/cos before adding them to the integer
+// pixel coordinate; FilterBilinear and FilterCatmullRom instead add fx+xo
+// and fy+yo as floats and resample the fractional result.
+func warpedOffset(x, y int, amplitude, dx, phase float64, shear Shear) (fx, fy, xo, yo float64) {
+	ox, oy := float64(x), float64(y)
+	fx = ox + shear.X*oy
+	fy = oy + shear.Y*ox
+
+	xo = amplitude * math.Sin(fy*dx+phase)
+	yo = amplitude * math.Cos(fx*dx+phase)
+	return fx, fy, xo, yo
+}
+
+// sampleRGBA reconstructs the color at fractional coordinates (x, y) using
+// the given Filter. FilterNearest is handled by distortPaletted's fast
+// path and never reaches here.
+func sampleRGBA(img *image.RGBA, x, y float64, filter Filter) color.RGBA {
+	if filter == FilterCatmullRom {
+		return sampleCatmullRom(img, x, y)
+	}
+	return sampleBilinear(img, x, y)
+}
+
+// clampedAt returns img's pixel at (x, y), clamping out-of-bounds
+// coordinates to the edge instead of wrapping or returning zero, so warps
+// that sample just past the border don't darken the edges.
+func clampedAt(img *image.RGBA, x, y int) color.RGBA {
+	b := img.Bounds()
+	switch {
+	case x < b.Min.X:
+		x = b.Min.X
+	case x >= b.Max.X:
+		x = b.Max.X - 1
+	}
+	switch {
+	case y < b.Min.Y:
+		y = b.Min.Y
+	case y >= b.Max.Y:
+		y = b.Max.Y - 1
+	}
+	return img.RGBAAt(x, y)
+}
+
+func sampleBilinear(img *image.RGBA, x, y float64) color.RGBA {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	c00 := clampedAt(img, int(x0), int(y0))
+	c10 := clampedAt(img, int(x0)+1, int(y0))
+	c01 := clampedAt(img, int(x0), int(y0)+1)
+	c11 := clampedAt(img, int(x0)+1, int(y0)+1)
+
+	return color.RGBA{
+		R: bilerp(c00.R, c10.R, c01.R, c11.R, fx, fy),
+		G: bilerp(c00.G, c10.G, c01.G, c11.G, fx, fy),
+		B: bilerp(c00.B, c10.B, c01.B, c11.B, fx, fy),
+		A: bilerp(c00.A, c10.A, c01.A, c11.A, fx, fy),
+	}
+}
+
+func bilerp(c00, c10, c01, c11 uint8, fx, fy float64) uint8 {
+	top := float64(c00)*(1-fx) + float64(c10)*fx
+	bottom := float64(c01)*(1-fx) + float64(c11)*fx
+	return clamp255(top*(1-fy) + bottom*fy)
+}
+
+// sampleCatmullRom reconstructs (x, y) from the 4x4 neighborhood of source
+// pixels around it, sharper than sampleBilinear at a higher sampling cost.
+func sampleCatmullRom(img *image.RGBA, x, y float64) color.RGBA {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	channel := func(pick func(color.RGBA) uint8) uint8 {
+		var cols [4]float64
+		for j := -1; j <= 2; j++ {
+			var row [4]float64
+			for i := -1; i <= 2; i++ {
+				row[i+1] = float64(pick(clampedAt(img, int(x0)+i, int(y0)+j)))
+			}
+			cols[j+1] = cubicCatmullRom(row[0], row[1], row[2], row[3], fx)
+		}
+		return clamp255(cubicCatmullRom(cols[0], cols[1], cols[2], cols[3], fy))
+	}
+
+	return color.RGBA{
+		R: channel(func(c color.RGBA) uint8 { return c.R }),
+		G: channel(func(c color.RGBA) uint8 { return c.G }),
+		B: channel(func(c color.RGBA) uint8 { return c.B }),
+		A: channel(func(c color.RGBA) uint8 { return c.A }),
+	}
+}
+
+// cubicCatmullRom evaluates the Catmull-Rom spline through p0..p3 at t in
+// [0, 1], where p1 and p2 are the samples on either side of t.
+func cubicCatmullRom(p0, p1, p2, p3, t float64) float64 {
+	return 0.5 * ((2 * p1) +
+		(-p0+p2)*t +
+		(2*p0-5*p1+4*p2-p3)*t*t +
+		(-p0+3*p1-3*p2+p3)*t*t*t)
+}
+
+func clamp255(v float64) uint8 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestWarpedOffset_ZeroAmplitudeNoShear(t *testing.T) {
+	for _, phase := range []float64{0, 1.23, 6.5} {
+		for _, p := range []struct{ x, y int }{{0, 0}, {7, 13}, {100, 42}} {
+			fx, fy, xo, yo := warpedOffset(p.x, p.y, 0, 2*math.Pi/137, phase, Shear{})
+			if fx != float64(p.x) || fy != float64(p.y) || xo != 0 || yo != 0 {
+				t.Errorf("warpedOffset(%d, %d, amp=0, phase=%v) = (%v, %v, %v, %v), want (%v, %v, 0, 0)",
+					p.x, p.y, phase, fx, fy, xo, yo, p.x, p.y)
+			}
+		}
+	}
+}
+
+func TestClampedAt_ClampsOutOfBounds(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	want := color.RGBA{10, 20, 30, 255}
+	img.SetRGBA(0, 0, want)
+
+	tests := []struct{ x, y int }{
+		{-5, -5}, {-1, 0}, {0, -1}, {-100, -100},
+	}
+	for _, tt := range tests {
+		if got := clampedAt(img, tt.x, tt.y); got != want {
+			t.Errorf("clampedAt(%d, %d) = %v, want %v (clamped to corner pixel)", tt.x, tt.y, got, want)
+		}
+	}
+}
+
+func TestSampleBilinear_UniformColorIsUnchanged(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	c := color.RGBA{50, 100, 150, 255}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for _, p := range []struct{ x, y float64 }{{3.0, 3.0}, {3.5, 3.5}, {2.25, 6.75}} {
+		if got := sampleBilinear(img, p.x, p.y); got != c {
+			t.Errorf("sampleBilinear(%v, %v) on a uniform image = %v, want %v", p.x, p.y, got, c)
+		}
+	}
+}
+
+func TestSampleCatmullRom_UniformColorIsUnchanged(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	c := color.RGBA{50, 100, 150, 255}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for _, p := range []struct{ x, y float64 }{{3.0, 3.0}, {3.5, 3.5}, {2.25, 6.75}} {
+		if got := sampleCatmullRom(img, p.x, p.y); got != c {
+			t.Errorf("sampleCatmullRom(%v, %v) on a uniform image = %v, want %v", p.x, p.y, got, c)
+		}
+	}
+}
+
+func TestCubicCatmullRom_PassesThroughControlPoints(t *testing.T) {
+	if got := cubicCatmullRom(0, 10, 20, 30, 0); got != 10 {
+		t.Errorf("cubicCatmullRom(..., t=0) = %v, want 10", got)
+	}
+	if got := cubicCatmullRom(0, 10, 20, 30, 1); got != 20 {
+		t.Errorf("cubicCatmullRom(..., t=1) = %v, want 20", got)
+	}
+}
+
+func TestDistortPaletted_PreservesBounds(t *testing.T) {
+	src := testImage().canvas
+	for _, filter := range []Filter{FilterNearest, FilterBilinear, FilterCatmullRom} {
+		out := distortPaletted(src, 6, 150, 0, filter, Shear{})
+		if out.Bounds() != src.Bounds() {
+			t.Errorf("filter %v: distortPaletted bounds = %v, want %v", filter, out.Bounds(), src.Bounds())
+		}
+	}
+}
+
+func TestDistortPaletted_FilterNearestMatchesOriginalTruncation(t *testing.T) {
+	// The pre-Filter distort truncated amplitude*sin/cos before adding it to
+	// the integer pixel coordinate (oldm.ColorIndexAt(x+int(xo), y+int(yo))),
+	// which differs from truncating x+xo as a whole whenever xo or yo has a
+	// negative fractional part.
+	src := testImage().canvas
+	w := src.Bounds().Max.X
+	h := src.Bounds().Max.Y
+	amp, period := 6.0, 150.0
+	dx := 2 * math.Pi / period
+
+	want := image.NewPaletted(image.Rect(0, 0, w, h), src.Palette)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			xo := amp * math.Sin(float64(y)*dx)
+			yo := amp * math.Cos(float64(x)*dx)
+			want.SetColorIndex(x, y, src.ColorIndexAt(x+int(xo), y+int(yo)))
+		}
+	}
+
+	got := distortPaletted(src, amp, period, 0, FilterNearest, Shear{})
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("distortPaletted with FilterNearest, phase 0, and zero Shear does not match the original distort's truncation")
+	}
+}
+
+func TestDistortPaletted_ShearIsAppliedBeforeWarp(t *testing.T) {
+	src := testImage().canvas
+	plain := distortPaletted(src, 0, 150, 0, FilterNearest, Shear{})
+	sheared := distortPaletted(src, 0, 150, 0, FilterNearest, Shear{X: 0.5})
+	if bytes.Equal(plain.Pix, sheared.Pix) {
+		t.Error("distortPaletted produced identical output with and without shear")
+	}
+}
+
+func TestWarpedOffset_YShearUsesOriginalX(t *testing.T) {
+	// fy must shear against the original x, not the already-X-sheared fx,
+	// or a Shear with both axes set picks up a spurious shear.X*shear.Y*y
+	// cross-term in fy.
+	x, y := 10, 20
+	shear := Shear{X: 0.5, Y: 0.25}
+	_, fy, _, _ := warpedOffset(x, y, 0, 2*math.Pi/137, 0, shear)
+
+	wantFy := float64(y) + shear.Y*float64(x)
+	if fy != wantFy {
+		t.Errorf("warpedOffset(%d, %d, shear=%+v) fy = %v, want %v", x, y, shear, fy, wantFy)
+	}
+}
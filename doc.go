@@ -0,0 +1,23 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package captcha generates and verifies image captchas: digit captchas
+// through NewImage/Server, wider-solver-space challenges through the
+// Driver-based MathDriver and AlphanumericDriver, and stateless,
+// HMAC-token-verified challenges through Captcha.NewChallenge for
+// deployments that can't share the package-level in-memory Store.
+//
+// Accessibility: this package intentionally does not ship an audio
+// captcha. An earlier revision added a "/<id>.wav" endpoint (NewAudio)
+// that rendered each digit as a synthesized tone picked from a secret
+// digit-to-pitch table rather than a recording or text-to-speech
+// rendition of the digit's spoken name; since that table was never
+// published anywhere a listener could read it, nobody could actually
+// solve the resulting audio, so it was removed rather than shipped as a
+// non-functional accessibility channel. A real audio alternative needs
+// recorded or TTS per-digit, per-language samples, which this package
+// does not bundle; a caller that needs one should synthesize or record
+// those samples and serve them keyed by the same id Digits and
+// VerifyString already use.
+package captcha
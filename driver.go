@@ -0,0 +1,160 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Driver abstracts generation and rendering of a captcha challenge. The
+// original digit captcha (NewLen/NewImage) predates this interface and
+// keeps working exactly as before; Driver exists for challenge types whose
+// solver space extends beyond the ten digit glyphs, such as MathDriver and
+// AlphanumericDriver.
+type Driver interface {
+	// Generate produces a fresh id, the correct answer, and the rendered
+	// challenge text that DrawImage will draw.
+	Generate() (id, answer string, challenge []byte)
+
+	// DrawImage renders challenge, as produced by Generate for id, into a
+	// new width x height captcha Image. id must be mixed into the image's
+	// PRNG seed the same way NewImage does, so that two different ids
+	// that happen to generate the same challenge text (e.g. two
+	// MathDriver challenges that both land on "12+7=?") don't render as
+	// pixel-identical, cacheable images.
+	DrawImage(id string, challenge []byte, width, height int, opts *DistortionOpts) *Image
+}
+
+// StringVerifier lets a Driver customize how a submitted answer is checked
+// against the one it generated, e.g. MathDriver comparing parsed integers
+// instead of requiring a byte-exact match. Drivers that don't implement it
+// are checked with a plain byte-equal comparison by VerifyDriverAnswer.
+type StringVerifier interface {
+	VerifyString(expected, answer string) bool
+}
+
+// AnswerNormalizer lets a Driver canonicalize a raw submitted answer into
+// the same form its Generate's answer is already in, e.g. MathDriver
+// folding "7", "07", and " 7" all to "7". Unlike StringVerifier, this
+// doesn't need the original plaintext answer, only the submission, so it
+// also works in hash-based verification such as Captcha.VerifyAnswer.
+type AnswerNormalizer interface {
+	NormalizeAnswer(raw string) (normalized string, ok bool)
+}
+
+// StatelessGenerator lets a Driver produce a challenge without registering
+// it in the package-level challenges map that VerifyDriverAnswer reads.
+// Captcha.NewChallenge uses this instead of Generate when the Driver
+// implements it, so the HMAC-token stateless flow doesn't leak an entry
+// into that map on every call; the classic Store-backed flow still goes
+// through Generate's own registration. Drivers that don't implement it
+// fall back to Generate in NewChallenge, same as before.
+type StatelessGenerator interface {
+	GenerateStateless() (id, answer string, challenge []byte)
+}
+
+// challengeTTL bounds how long registerChallenge keeps an entry alive
+// without it being claimed by VerifyDriverAnswer. It mirrors
+// defaultTokenTTL, the equivalent bound on the stateless API's tokens.
+const challengeTTL = 10 * time.Minute
+
+type challengeRecord struct {
+	answer    string
+	verifier  StringVerifier // nil: plain byte-equal comparison
+	expiresAt time.Time
+}
+
+var (
+	challengeMu sync.Mutex
+	challenges  = map[string]challengeRecord{}
+)
+
+// registerChallenge remembers id's answer, and how to compare it if d
+// implements StringVerifier, so a later VerifyDriverAnswer call can check
+// a submission. Generate implementations in this package call it so
+// driver-issued ids can be verified the same way Store-issued ones are.
+//
+// Callers that never check their id this way, e.g. Captcha.NewChallenge's
+// stateless flow (which verifies an HMAC token instead), would otherwise
+// leak an entry per Generate call forever; registerChallenge sweeps
+// expired entries on every call to keep challenges bounded to roughly
+// challengeTTL's worth of registrations regardless of whether callers
+// ever verify.
+func registerChallenge(id, answer string, d Driver) {
+	rec := challengeRecord{answer: answer, expiresAt: time.Now().Add(challengeTTL)}
+	if v, ok := d.(StringVerifier); ok {
+		rec.verifier = v
+	}
+	challengeMu.Lock()
+	sweepExpiredChallengesLocked()
+	challenges[id] = rec
+	challengeMu.Unlock()
+}
+
+// sweepExpiredChallengesLocked deletes every challenges entry past its
+// expiresAt. challengeMu must be held by the caller.
+func sweepExpiredChallengesLocked() {
+	now := time.Now()
+	for id, rec := range challenges {
+		if now.After(rec.expiresAt) {
+			delete(challenges, id)
+		}
+	}
+}
+
+// VerifyDriverAnswer reports whether answer is correct for id, and
+// whether id was a Driver-issued challenge registerChallenge knows about
+// and hasn't expired. Like Digits/Reload, checking consumes the
+// registration, so a given id can only be verified once. Callers checking
+// an id that might come from either a Driver or the classic digit Store
+// should try VerifyDriverAnswer first and fall back to VerifyString when
+// ok is false.
+func VerifyDriverAnswer(id, answer string) (verified, ok bool) {
+	challengeMu.Lock()
+	rec, found := challenges[id]
+	if found {
+		delete(challenges, id)
+	}
+	challengeMu.Unlock()
+
+	if !found || time.Now().After(rec.expiresAt) {
+		return false, false
+	}
+	if rec.verifier != nil {
+		return rec.verifier.VerifyString(rec.answer, answer), true
+	}
+	return rec.answer == answer, true
+}
+
+const idAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+const defaultDriverIDLen = 20
+
+// randomID returns a random alphanumeric id of length n, suitable for a
+// Driver's Generate.
+func randomID(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = idAlphabet[randIntn(len(idAlphabet))]
+	}
+	return string(b)
+}
+
+// randIntn returns a uniform random int in [0, n) from a CSPRNG. Drivers
+// need randomness independent of any particular captcha id, unlike Image's
+// deterministic per-id siprng, so they don't share m.rng.
+func randIntn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(v.Int64())
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMathDriver_GenerateAndVerify(t *testing.T) {
+	d := &MathDriver{}
+	id, answer, challenge := d.Generate()
+
+	if id == "" {
+		t.Fatal("Generate returned empty id")
+	}
+	if len(challenge) == 0 {
+		t.Fatal("Generate returned empty challenge")
+	}
+
+	verified, ok := VerifyDriverAnswer(id, answer)
+	if !ok {
+		t.Fatal("VerifyDriverAnswer didn't recognize a just-registered id")
+	}
+	if !verified {
+		t.Errorf("VerifyDriverAnswer(%q, %q) = false, want true", id, answer)
+	}
+}
+
+// TestMathDriver_OperandsChainsMultipleOperators guards the "9*7-3=?" shape
+// described for MathDriver: with Operands set above 2, Generate must chain
+// that many numbers with Operands-1 operators, and answer must be the
+// left-to-right evaluation of exactly what's rendered.
+func TestMathDriver_OperandsChainsMultipleOperators(t *testing.T) {
+	d := &MathDriver{Operators: []MathOperator{OpAdd, OpSub, OpMul}, OperandMin: 1, OperandMax: 9, Operands: 3}
+	_, answer, challenge := d.Generate()
+
+	expr := strings.TrimSuffix(string(challenge), "=?")
+	operands, ops := splitExpr(t, expr)
+	if len(operands) != 3 {
+		t.Fatalf("challenge %q has %d operands, want 3", challenge, len(operands))
+	}
+
+	result := operands[0]
+	for i, op := range ops {
+		result = apply(result, op, operands[i+1])
+	}
+	if got := strconv.Itoa(result); got != answer {
+		t.Errorf("answer = %q, want %q for challenge %q", answer, got, challenge)
+	}
+}
+
+// splitExpr parses a "%d%c%d%c%d..." expression (no "=?" suffix) back into
+// its operands and operators, for asserting Generate's output shape.
+func splitExpr(t *testing.T, expr string) (operands []int, ops []MathOperator) {
+	t.Helper()
+	start := 0
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		isOp := (c == byte(OpAdd) || c == byte(OpSub) || c == byte(OpMul)) && i > start
+		if isOp {
+			n, err := strconv.Atoi(expr[start:i])
+			if err != nil {
+				t.Fatalf("parsing operand from %q: %v", expr, err)
+			}
+			operands = append(operands, n)
+			ops = append(ops, MathOperator(c))
+			start = i + 1
+		}
+	}
+	n, err := strconv.Atoi(expr[start:])
+	if err != nil {
+		t.Fatalf("parsing final operand from %q: %v", expr, err)
+	}
+	operands = append(operands, n)
+	return operands, ops
+}
+
+func TestMathDriver_VerifyStringNormalizes(t *testing.T) {
+	d := &MathDriver{}
+	tests := []struct {
+		expected, answer string
+		want             bool
+	}{
+		{"7", "7", true},
+		{"7", "07", true},
+		{"7", " 7 ", true},
+		{"7", "8", false},
+		{"7", "not a number", false},
+	}
+	for _, tt := range tests {
+		if got := d.VerifyString(tt.expected, tt.answer); got != tt.want {
+			t.Errorf("VerifyString(%q, %q) = %v, want %v", tt.expected, tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyDriverAnswer_SingleUse(t *testing.T) {
+	d := &AlphanumericDriver{}
+	id, answer, _ := d.Generate()
+
+	if verified, ok := VerifyDriverAnswer(id, answer); !ok || !verified {
+		t.Fatalf("first VerifyDriverAnswer: verified=%v ok=%v, want true, true", verified, ok)
+	}
+	if _, ok := VerifyDriverAnswer(id, answer); ok {
+		t.Error("VerifyDriverAnswer succeeded twice for the same id; registration should be consumed")
+	}
+}
+
+func TestAlnumGlypher_CaseInsensitiveGlyph(t *testing.T) {
+	g := alnumGlypher{}
+	if g.Glyph('k') != g.Glyph('K') {
+		t.Error("alnumGlypher renders 'k' and 'K' with different glyphs, but the driver answer is case-insensitive")
+	}
+}
+
+func TestAlphanumericDriver_VerifyIgnoresCase(t *testing.T) {
+	d := &AlphanumericDriver{Charset: "Kk", Length: 1}
+	id, answer, _ := d.Generate()
+
+	opposite := "k"
+	if answer == "k" {
+		opposite = "K"
+	}
+	if verified, ok := VerifyDriverAnswer(id, opposite); !ok || !verified {
+		t.Errorf("VerifyDriverAnswer(%q) = verified=%v ok=%v, want true, true (answer was %q)", opposite, verified, ok, answer)
+	}
+}
+
+func TestAlphanumericDriver_NormalizeAnswer(t *testing.T) {
+	d := &AlphanumericDriver{}
+	got, ok := d.NormalizeAnswer("aBc")
+	if !ok || got != "ABC" {
+		t.Errorf("NormalizeAnswer(%q) = %q, %v, want %q, true", "aBc", got, ok, "ABC")
+	}
+}
+
+func TestVerifyDriverAnswer_Expired(t *testing.T) {
+	d := &MathDriver{}
+	id, answer, _ := d.Generate()
+
+	challengeMu.Lock()
+	rec := challenges[id]
+	rec.expiresAt = time.Now().Add(-time.Second)
+	challenges[id] = rec
+	challengeMu.Unlock()
+
+	if verified, ok := VerifyDriverAnswer(id, answer); ok || verified {
+		t.Errorf("VerifyDriverAnswer on an expired id = verified=%v ok=%v, want false, false", verified, ok)
+	}
+}
+
+func TestRegisterChallenge_SweepsExpired(t *testing.T) {
+	staleID := "stale-test-id"
+	challengeMu.Lock()
+	challenges[staleID] = challengeRecord{answer: "x", expiresAt: time.Now().Add(-time.Minute)}
+	challengeMu.Unlock()
+
+	d := &MathDriver{}
+	d.Generate() // any registerChallenge call should sweep staleID away
+
+	challengeMu.Lock()
+	_, stillThere := challenges[staleID]
+	challengeMu.Unlock()
+	if stillThere {
+		t.Error("registerChallenge didn't sweep an already-expired entry; challenges map leaks unbounded")
+	}
+}
+
+func TestDriver_DrawImageSeedsOnID(t *testing.T) {
+	d := &MathDriver{}
+	challenge := []byte("12+7=?")
+
+	imgA := d.DrawImage("id-one", challenge, StdWidth, StdHeight, nil)
+	imgB := d.DrawImage("id-two", challenge, StdWidth, StdHeight, nil)
+
+	if bytes.Equal(imgA.Paletted.Pix, imgB.Paletted.Pix) {
+		t.Error("DrawImage produced identical pixels for two different ids with the same challenge text")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Encoder renders an Image in a particular wire format.
+type Encoder interface {
+	// ContentType is the MIME type Encode's output should be served with.
+	ContentType() string
+	// Encode writes img to w in this Encoder's format.
+	Encode(w io.Writer, img *Image) error
+}
+
+// PNGEncoder encodes a captcha as a single PNG frame, the format captcha
+// has always served.
+type PNGEncoder struct {
+	CompressionLevel png.CompressionLevel
+}
+
+// ContentType implements Encoder.
+func (e PNGEncoder) ContentType() string { return "image/png" }
+
+// Encode implements Encoder.
+func (e PNGEncoder) Encode(w io.Writer, img *Image) error {
+	enc := png.Encoder{CompressionLevel: e.CompressionLevel}
+	return enc.Encode(w, img.Paletted)
+}
+
+// JPEGEncoder encodes a captcha as a JPEG. Its lossy compression adds
+// another, differently-shaped layer of noise on top of the distortion
+// already applied to the digits.
+type JPEGEncoder struct {
+	// Quality is passed to image/jpeg; defaults to jpeg.DefaultQuality
+	// when zero.
+	Quality int
+}
+
+// ContentType implements Encoder.
+func (e JPEGEncoder) ContentType() string { return "image/jpeg" }
+
+func (e JPEGEncoder) quality() int {
+	if e.Quality == 0 {
+		return jpeg.DefaultQuality
+	}
+	return e.Quality
+}
+
+// Encode implements Encoder.
+func (e JPEGEncoder) Encode(w io.Writer, img *Image) error {
+	return jpeg.Encode(w, img.Paletted, &jpeg.Options{Quality: e.quality()})
+}
+
+const (
+	defaultGIFFrameCount = 4
+	defaultGIFFrameDelay = 10 // 100ths of a second
+)
+
+// GIFEncoder encodes a captcha as an animated GIF. Rendering FrameCount
+// phase-shifted distortions of the same digits (via Image.Frames) and
+// looping through them raises the bar for OCR, which must reconcile
+// motion across frames instead of attacking one static image.
+type GIFEncoder struct {
+	// FrameCount is the number of animation frames; defaults to 4 when
+	// zero.
+	FrameCount int
+	// FrameDelay is the per-frame display delay, in 100ths of a second,
+	// passed to image/gif; defaults to 10 (100ms) when zero.
+	FrameDelay int
+}
+
+// ContentType implements Encoder.
+func (e GIFEncoder) ContentType() string { return "image/gif" }
+
+func (e GIFEncoder) frameCount() int {
+	if e.FrameCount == 0 {
+		return defaultGIFFrameCount
+	}
+	return e.FrameCount
+}
+
+func (e GIFEncoder) frameDelay() int {
+	if e.FrameDelay == 0 {
+		return defaultGIFFrameDelay
+	}
+	return e.FrameDelay
+}
+
+// Encode implements Encoder.
+func (e GIFEncoder) Encode(w io.Writer, img *Image) error {
+	frames := img.Frames(e.frameCount())
+
+	g := &gif.GIF{}
+	for _, frame := range frames {
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, e.frameDelay())
+	}
+	return gif.EncodeAll(w, g)
+}
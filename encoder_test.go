@@ -0,0 +1,99 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"bytes"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage() *Image {
+	return NewImage("encoder-test-id", []byte{1, 2, 3, 4}, StdWidth, StdHeight, nil)
+}
+
+func TestPNGEncoder(t *testing.T) {
+	e := PNGEncoder{CompressionLevel: png.BestSpeed}
+	if e.ContentType() != "image/png" {
+		t.Errorf("ContentType = %q, want %q", e.ContentType(), "image/png")
+	}
+
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, testImage()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode of encoded output: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != StdWidth || b.Dy() != StdHeight {
+		t.Errorf("decoded bounds = %v, want %dx%d", b, StdWidth, StdHeight)
+	}
+}
+
+func TestJPEGEncoder(t *testing.T) {
+	e := JPEGEncoder{}
+	if e.ContentType() != "image/jpeg" {
+		t.Errorf("ContentType = %q, want %q", e.ContentType(), "image/jpeg")
+	}
+	if got := e.quality(); got != jpeg.DefaultQuality {
+		t.Errorf("zero-value Quality = %d, want jpeg.DefaultQuality (%d)", got, jpeg.DefaultQuality)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, testImage()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	img, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("jpeg.Decode of encoded output: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != StdWidth || b.Dy() != StdHeight {
+		t.Errorf("decoded bounds = %v, want %dx%d", b, StdWidth, StdHeight)
+	}
+}
+
+func TestJPEGEncoder_CustomQuality(t *testing.T) {
+	e := JPEGEncoder{Quality: 50}
+	if got := e.quality(); got != 50 {
+		t.Errorf("quality() = %d, want 50", got)
+	}
+}
+
+func TestGIFEncoder_DefaultFrameCount(t *testing.T) {
+	e := GIFEncoder{}
+	if got := e.frameCount(); got != defaultGIFFrameCount {
+		t.Errorf("zero-value FrameCount = %d, want %d", got, defaultGIFFrameCount)
+	}
+	if got := e.frameDelay(); got != defaultGIFFrameDelay {
+		t.Errorf("zero-value FrameDelay = %d, want %d", got, defaultGIFFrameDelay)
+	}
+}
+
+func TestGIFEncoder_Encode(t *testing.T) {
+	e := GIFEncoder{FrameCount: 3, FrameDelay: 25}
+	if e.ContentType() != "image/gif" {
+		t.Errorf("ContentType = %q, want %q", e.ContentType(), "image/gif")
+	}
+
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, testImage()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll of encoded output: %v", err)
+	}
+	if len(g.Image) != 3 {
+		t.Errorf("frame count = %d, want 3", len(g.Image))
+	}
+	for i, delay := range g.Delay {
+		if delay != 25 {
+			t.Errorf("frame %d delay = %d, want 25", i, delay)
+		}
+	}
+}
@@ -32,12 +32,42 @@ type WarpBounds struct {
 	PeriodMin, PeriodMax float64
 }
 
+// Filter selects the resampling kernel distort uses when warping an image.
+type Filter int
+
+const (
+	// FilterNearest truncates to the nearest source pixel, same as
+	// distort has always done. It's the cheapest option but produces
+	// blocky aliasing, which limits how aggressive CanvasWarp can be
+	// before digits become unreadable.
+	FilterNearest Filter = iota
+	// FilterBilinear interpolates the four nearest source pixels.
+	FilterBilinear
+	// FilterCatmullRom interpolates a 4x4 neighborhood of source pixels
+	// for sharper results than FilterBilinear, at higher CPU cost.
+	FilterCatmullRom
+)
+
+// Shear is an affine x/y shear factor applied to the image before the sine
+// warp. X shifts a row sideways in proportion to its y coordinate; Y shifts
+// a column vertically in proportion to its x coordinate.
+type Shear struct {
+	X, Y float64
+}
+
 type DistortionOpts struct {
 	CircleCount int
 	StrikeCount int
 	MaxSkew     float64
 	CanvasWarp  WarpBounds
 	StrikeWarp  WarpBounds
+
+	// Filter selects the resampling kernel used when warping the canvas.
+	// Defaults to FilterNearest (the zero value).
+	Filter Filter
+	// Shear is applied to the canvas before the sine warp. Defaults to no
+	// shear (the zero value).
+	Shear Shear
 }
 
 type Image struct {
@@ -46,6 +76,12 @@ type Image struct {
 	numHeight int
 	dotSize   int
 	rng       siprng
+
+	// canvas and opts are retained after rendering so GIFEncoder can
+	// generate additional phase-shifted distortions of the same digits
+	// for an animated captcha; see Frames.
+	canvas *image.Paletted
+	opts   *DistortionOpts
 }
 
 var defaultCanvasWarp = WarpBounds{
@@ -66,24 +102,56 @@ var defaultDistortionOpts = DistortionOpts{
 	StrikeWarp:  defaultStrikeWarp,
 }
 
+// Glypher resolves a single challenge character to the bitmap that should
+// be drawn for it. It lets Driver implementations whose challenges are not
+// limited to the digits 0-9 (see driver.go) reuse the image-rendering
+// pipeline below with their own font.
+type Glypher interface {
+	Glyph(r rune) *charMap
+}
+
+// digitGlypher resolves '0'-'9' to the built-in digit font, reproducing the
+// behavior NewImage has always had.
+type digitGlypher struct{}
+
+func (digitGlypher) Glyph(r rune) *charMap {
+	return font[byte(r-'0')]
+}
+
 // NewImage returns a new captcha image of the given width and height with the
 // given digits, where each digit must be in range 0-9.
 func NewImage(id string, digits []byte, width, height int, opts *DistortionOpts) *Image {
+	challenge := make([]rune, len(digits))
+	for i, d := range digits {
+		challenge[i] = rune('0' + d)
+	}
+	return newImage(id, digits, challenge, digitGlypher{}, width, height, opts)
+}
+
+// newImage renders challenge (resolved rune-by-rune through g) into a new
+// width x height captcha Image, seeding its PRNG from id and seed the same
+// way NewImage always has. It is the shared core behind NewImage and the
+// Driver implementations in driver.go, mathdriver.go, and
+// alphanumericdriver.go.
+func newImage(id string, seed []byte, challenge []rune, g Glypher, width, height int, opts *DistortionOpts) *Image {
 	if opts == nil {
 		opts = &defaultDistortionOpts
 	}
+	if g == nil {
+		g = digitGlypher{}
+	}
 
 	m := new(Image)
 
 	// Initialize PRNG.
-	m.rng.Seed(deriveSeed(imageSeedPurpose, id, digits))
+	m.rng.Seed(deriveSeed(imageSeedPurpose, id, seed))
 
 	m.Paletted = image.NewPaletted(image.Rect(0, 0, width, height),
 		m.getRandomPalette(opts.CircleCount))
-	m.calculateSizes(width, height, len(digits))
+	m.calculateSizes(width, height, len(challenge))
 
 	// Randomly position captcha inside the image.
-	maxx := width - (m.numWidth+m.dotSize)*len(digits) - m.dotSize
+	maxx := width - (m.numWidth+m.dotSize)*len(challenge) - m.dotSize
 	maxy := height - m.numHeight - m.dotSize*2
 	var border int
 	if width > height {
@@ -94,9 +162,9 @@ func NewImage(id string, digits []byte, width, height int, opts *DistortionOpts)
 	x := m.rng.Int(border, maxx-border)
 	y := m.rng.Int(border, maxy-border)
 
-	// Draw digits.
-	for _, n := range digits {
-		m.drawDigit(font[n], x, y, opts.MaxSkew)
+	// Draw challenge characters.
+	for _, r := range challenge {
+		m.drawDigit(g.Glyph(r), x, y, opts.MaxSkew)
 		x += m.numWidth + m.dotSize
 	}
 
@@ -106,6 +174,12 @@ func NewImage(id string, digits []byte, width, height int, opts *DistortionOpts)
 			opts.StrikeWarp.PeriodMin, opts.StrikeWarp.PeriodMax)
 	}
 
+	// Keep the pre-distortion canvas and opts so GIFEncoder can later
+	// render additional phase-shifted frames from the same digits; see
+	// Frames.
+	m.canvas = clonePaletted(m.Paletted)
+	m.opts = opts
+
 	// Apply wave distortion.
 	amp := m.rng.Float(opts.CanvasWarp.AmpMin, opts.CanvasWarp.AmpMax)
 	per := m.rng.Float(opts.CanvasWarp.PeriodMin, opts.CanvasWarp.PeriodMax)
@@ -117,6 +191,39 @@ func NewImage(id string, digits []byte, width, height int, opts *DistortionOpts)
 	return m
 }
 
+// Frames renders n phase-shifted variants of this Image's pre-distortion
+// canvas, each with its own randomly drawn amplitude, period, and circle
+// fill, for GIFEncoder's animated output. It returns just the final,
+// already-distorted frame n times if the image wasn't built with enough
+// state to re-render (e.g. a zero-value Image).
+func (m *Image) Frames(n int) []*image.Paletted {
+	if m.canvas == nil || m.opts == nil || n <= 0 {
+		return []*image.Paletted{m.Paletted}
+	}
+
+	frames := make([]*image.Paletted, n)
+	for i := 0; i < n; i++ {
+		phase := 2 * math.Pi * float64(i) / float64(n)
+		amp := m.rng.Float(m.opts.CanvasWarp.AmpMin, m.opts.CanvasWarp.AmpMax)
+		per := m.rng.Float(m.opts.CanvasWarp.PeriodMin, m.opts.CanvasWarp.PeriodMax)
+
+		frame := distortPaletted(m.canvas, amp, per, phase, m.opts.Filter, m.opts.Shear)
+		tmp := &Image{Paletted: frame, dotSize: m.dotSize, rng: m.rng}
+		tmp.fillWithCircles(m.opts.CircleCount, m.dotSize)
+		m.rng = tmp.rng
+
+		frames[i] = frame
+	}
+	return frames
+}
+
+// clonePaletted returns a deep copy of p.
+func clonePaletted(p *image.Paletted) *image.Paletted {
+	c := *p
+	c.Pix = append([]byte(nil), p.Pix...)
+	return &c
+}
+
 func (m *Image) getRandomPalette(circleCount int) color.Palette {
 	p := make([]color.Color, circleCount+1)
 	// Transparent color.
@@ -267,21 +374,12 @@ func (m *Image) drawDigit(digit *charMap, x, y int, MaxSkew float64) {
 }
 
 func (m *Image) distort(amplude float64, period float64) {
-	w := m.Bounds().Max.X
-	h := m.Bounds().Max.Y
-
-	oldm := m.Paletted
-	newm := image.NewPaletted(image.Rect(0, 0, w, h), oldm.Palette)
-
-	dx := 2.0 * math.Pi / period
-	for x := 0; x < w; x++ {
-		for y := 0; y < h; y++ {
-			xo := amplude * math.Sin(float64(y)*dx)
-			yo := amplude * math.Cos(float64(x)*dx)
-			newm.SetColorIndex(x, y, oldm.ColorIndexAt(x+int(xo), y+int(yo)))
-		}
+	var filter Filter
+	var shear Shear
+	if m.opts != nil {
+		filter, shear = m.opts.Filter, m.opts.Shear
 	}
-	m.Paletted = newm
+	m.Paletted = distortPaletted(m.Paletted, amplude, period, 0, filter, shear)
 }
 
 func (m *Image) randomBrightness(c color.RGBA, max uint8) color.RGBA {
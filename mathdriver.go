@@ -0,0 +1,150 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MathOperator is a binary arithmetic operator a MathDriver can render,
+// e.g. the '+' in "28+58=?".
+type MathOperator byte
+
+// Operators supported by MathDriver.
+const (
+	OpAdd MathOperator = '+'
+	OpSub MathOperator = '-'
+	OpMul MathOperator = '*'
+)
+
+// MathDriver generates simple arithmetic challenges such as "28+58=?" or,
+// with Operands set to 3, "9*7-3=?", widening the solver space far beyond
+// the ten digit captchas NewImage produces on its own. The zero value is
+// ready to use.
+type MathDriver struct {
+	// Operators is the set of operators to draw from. Defaults to
+	// {OpAdd, OpSub} when empty.
+	Operators []MathOperator
+	// OperandMin and OperandMax bound each random operand, inclusive.
+	// Default to 1 and 99 when OperandMax is zero.
+	OperandMin, OperandMax int
+	// Operands is how many numbers Generate chains together with
+	// Operands-1 random operators, evaluated left to right with no
+	// operator precedence, e.g. 2 for "28+58=?" or 3 for "9*7-3=?".
+	// Defaults to 2 when less than 2.
+	Operands int
+	// IDLen is the length of generated ids. Defaults to 20 when zero.
+	IDLen int
+}
+
+func (d *MathDriver) operators() []MathOperator {
+	if len(d.Operators) == 0 {
+		return []MathOperator{OpAdd, OpSub}
+	}
+	return d.Operators
+}
+
+func (d *MathDriver) operandRange() (lo, hi int) {
+	lo, hi = d.OperandMin, d.OperandMax
+	if hi == 0 {
+		lo, hi = 1, 99
+	}
+	return
+}
+
+func (d *MathDriver) idLen() int {
+	if d.IDLen == 0 {
+		return defaultDriverIDLen
+	}
+	return d.IDLen
+}
+
+func (d *MathDriver) operands() int {
+	if d.Operands < 2 {
+		return 2
+	}
+	return d.Operands
+}
+
+// apply evaluates op on the running result and the next operand.
+func apply(result int, op MathOperator, operand int) int {
+	switch op {
+	case OpAdd:
+		return result + operand
+	case OpSub:
+		return result - operand
+	case OpMul:
+		return result * operand
+	}
+	return result
+}
+
+// Generate implements Driver.
+func (d *MathDriver) Generate() (id, answer string, challenge []byte) {
+	id, answer, challenge = d.generate()
+	registerChallenge(id, answer, d)
+	return id, answer, challenge
+}
+
+// GenerateStateless implements StatelessGenerator, producing the same kind
+// of challenge as Generate without registering it in the package-level
+// challenges map.
+func (d *MathDriver) GenerateStateless() (id, answer string, challenge []byte) {
+	return d.generate()
+}
+
+func (d *MathDriver) generate() (id, answer string, challenge []byte) {
+	ops := d.operators()
+	lo, hi := d.operandRange()
+	n := d.operands()
+
+	var expr strings.Builder
+	result := lo + randIntn(hi-lo+1)
+	fmt.Fprintf(&expr, "%d", result)
+	for i := 1; i < n; i++ {
+		op := ops[randIntn(len(ops))]
+		operand := lo + randIntn(hi-lo+1)
+		result = apply(result, op, operand)
+		fmt.Fprintf(&expr, "%c%d", byte(op), operand)
+	}
+	expr.WriteString("=?")
+
+	id = randomID(d.idLen())
+	answer = strconv.Itoa(result)
+	return id, answer, []byte(expr.String())
+}
+
+// DrawImage implements Driver.
+func (d *MathDriver) DrawImage(id string, challenge []byte, width, height int, opts *DistortionOpts) *Image {
+	runes := []rune(string(challenge))
+	return newImage(id, challenge, runes, alnumGlypher{}, width, height, opts)
+}
+
+// NormalizeAnswer implements AnswerNormalizer, canonicalizing raw to the
+// decimal form Generate's answer is already in, so Captcha.VerifyAnswer
+// can compare normalized forms of a hash-based token.
+func (d *MathDriver) NormalizeAnswer(raw string) (normalized string, ok bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	return strconv.Itoa(n), true
+}
+
+// VerifyString implements StringVerifier, reporting whether answer solves
+// expected by comparing the parsed numeric value rather than requiring a
+// byte-exact match (so "7" matches an expected answer of "07", and
+// surrounding whitespace is ignored). VerifyDriverAnswer routes through
+// this for ids registered by Generate.
+func (d *MathDriver) VerifyString(expected, answer string) bool {
+	want, ok := d.NormalizeAnswer(expected)
+	if !ok {
+		return false
+	}
+	got, ok := d.NormalizeAnswer(answer)
+	return ok && want == got
+}
@@ -0,0 +1,20 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MountChi registers m's image handler onto r under Config.Prefix. Verify
+// needs no chi-specific counterpart: chi handlers receive a plain
+// *http.Request, so Verify(r) works as-is.
+func (m *Middleware) MountChi(r chi.Router) {
+	prefix := strings.TrimSuffix(m.cfg.Prefix, "/")
+	r.Handle(prefix+"/*", http.StripPrefix(prefix, m.handler))
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MountGin registers m's image handler onto r under Config.Prefix.
+func (m *Middleware) MountGin(r gin.IRouter) {
+	prefix := strings.TrimSuffix(m.cfg.Prefix, "/")
+	h := gin.WrapH(http.StripPrefix(prefix, m.handler))
+	r.GET(prefix+"/*filepath", h)
+}
+
+// VerifyGin is Verify for a gin.Context, reading FieldID and
+// FieldSolution from c.Request's form.
+func (m *Middleware) VerifyGin(c *gin.Context) bool {
+	return m.Verify(c.Request)
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package middleware wraps captcha's image server and string verification
+// into idiomatic middlewares for net/http, chi, and gin, so callers don't
+// have to hand-roll the id-minting, template, and form-verification glue
+// themselves.
+package middleware
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chappjc/captcha"
+)
+
+// Form field names the template helper's hidden input and a solved
+// captcha's POSTed solution are expected under.
+const (
+	FieldID       = "captcha_id"
+	FieldSolution = "captcha_solution"
+)
+
+// Config configures a Middleware.
+type Config struct {
+	// Prefix is the URL path prefix the image handler is mounted under,
+	// e.g. "/captcha/". Defaults to "/captcha/" when empty.
+	Prefix string
+
+	// ImageWidth and ImageHeight default to captcha.StdWidth and
+	// captcha.StdHeight when zero.
+	ImageWidth, ImageHeight int
+	// DistortionOpts is passed through to captcha.Server; nil selects the
+	// package defaults.
+	DistortionOpts *captcha.DistortionOpts
+
+	// SessionID, when set, returns a stable identifier for the request's
+	// session (e.g. read from a cookie). When set, a captcha minted for
+	// one session cannot be verified from a request belonging to another,
+	// and each minted captcha can be verified at most once.
+	SessionID func(*http.Request) string
+}
+
+// sessionBindingTTL bounds how long Middleware.New's session binding stays
+// alive for an id that's never submitted to Verify. Without it, m.session
+// would grow by one entry per New call forever, since checkSession is the
+// only thing that ever deletes one.
+const sessionBindingTTL = 10 * time.Minute
+
+type sessionBinding struct {
+	sessionID string
+	expiresAt time.Time
+}
+
+// Middleware mounts captcha's image handler under a configurable prefix
+// and verifies submitted solutions, optionally binding each captcha to the
+// session that requested it so a solved captcha can't be replayed
+// elsewhere.
+type Middleware struct {
+	cfg     Config
+	handler http.Handler
+
+	mu      sync.Mutex
+	session map[string]sessionBinding // captcha id -> session it was minted for
+}
+
+// New returns a Middleware built from cfg.
+func New(cfg Config) *Middleware {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "/captcha/"
+	}
+	if cfg.ImageWidth == 0 {
+		cfg.ImageWidth = captcha.StdWidth
+	}
+	if cfg.ImageHeight == 0 {
+		cfg.ImageHeight = captcha.StdHeight
+	}
+	return &Middleware{
+		cfg:     cfg,
+		handler: captcha.Server(cfg.ImageWidth, cfg.ImageHeight, cfg.DistortionOpts),
+		session: make(map[string]sessionBinding),
+	}
+}
+
+// Handler returns the captcha image handler for mounting under net/http,
+// typically via http.StripPrefix(strings.TrimSuffix(cfg.Prefix, "/"), ...).
+func (m *Middleware) Handler() http.Handler {
+	return m.handler
+}
+
+// New mints a fresh captcha id for r's session (if Config.SessionID is
+// set) and returns it alongside ready-to-embed HTML: an <img> tag pointing
+// at the mounted image handler, plus a hidden input carrying the id under
+// FieldID.
+func (m *Middleware) New(r *http.Request) (id string, html template.HTML) {
+	id = captcha.NewLen(6)
+	if m.cfg.SessionID != nil {
+		m.bind(id, m.cfg.SessionID(r))
+	}
+	html = template.HTML(fmt.Sprintf(
+		`<img src="%s%s.png" alt="captcha"><input type="hidden" name="%s" value="%s">`,
+		m.cfg.Prefix, id, FieldID, id,
+	))
+	return id, html
+}
+
+func (m *Middleware) bind(id, sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepExpiredSessionsLocked()
+	m.session[id] = sessionBinding{sessionID: sessionID, expiresAt: time.Now().Add(sessionBindingTTL)}
+}
+
+// sweepExpiredSessionsLocked deletes every m.session entry past its
+// expiresAt, so an id minted by New but never submitted to Verify doesn't
+// leak a binding forever. m.mu must be held by the caller.
+func (m *Middleware) sweepExpiredSessionsLocked() {
+	now := time.Now()
+	for id, b := range m.session {
+		if now.After(b.expiresAt) {
+			delete(m.session, id)
+		}
+	}
+}
+
+// checkSession reports whether id was minted for sessionID. It consumes
+// the binding only when that's actually the case (or when it's found
+// expired, since there's nothing left worth keeping), so that a wrong or
+// garbage sessionID submitted against someone else's id doesn't destroy
+// the legitimate owner's still-valid binding out from under them.
+func (m *Middleware) checkSession(id, sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bound, ok := m.session[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(bound.expiresAt) {
+		delete(m.session, id)
+		return false
+	}
+	if bound.sessionID != sessionID {
+		return false
+	}
+	delete(m.session, id)
+	return true
+}
+
+// Verify reads FieldID and FieldSolution from r's form and reports whether
+// the solution is correct. If Config.SessionID is set, it also requires
+// that id was minted by Middleware.New for this same request's session,
+// and that this is the first time it's been checked.
+func (m *Middleware) Verify(r *http.Request) bool {
+	id := r.FormValue(FieldID)
+	solution := r.FormValue(FieldSolution)
+	if id == "" || solution == "" {
+		return false
+	}
+
+	if m.cfg.SessionID != nil && !m.checkSession(id, m.cfg.SessionID(r)) {
+		return false
+	}
+
+	return captcha.VerifyString(id, solution)
+}
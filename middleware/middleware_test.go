@@ -0,0 +1,167 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chappjc/captcha"
+)
+
+func postForm(id, solution string) *http.Request {
+	form := url.Values{FieldID: {id}, FieldSolution: {solution}}
+	r := httptest.NewRequest("POST", "http://example.com/verify", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func newSolvedID(t *testing.T) (id, answer string) {
+	t.Helper()
+	id = captcha.NewLen(6)
+	digits := captcha.Digits(id)
+	if digits == nil {
+		t.Fatal("captcha.Digits returned nil for a freshly minted id")
+	}
+	b := make([]byte, len(digits))
+	for i, d := range digits {
+		b[i] = '0' + d
+	}
+	return id, string(b)
+}
+
+func TestMiddleware_New(t *testing.T) {
+	m := New(Config{Prefix: "/cap/"})
+	id, html := m.New(httptest.NewRequest("GET", "http://example.com/", nil))
+	if id == "" {
+		t.Fatal("New returned an empty id")
+	}
+	if !strings.Contains(string(html), "/cap/"+id+".png") {
+		t.Errorf("html doesn't reference the minted id's image: %s", html)
+	}
+	if !strings.Contains(string(html), `name="`+FieldID+`" value="`+id+`"`) {
+		t.Errorf("html doesn't carry the id in a %s hidden input: %s", FieldID, html)
+	}
+}
+
+func TestMiddleware_Verify_NoSessionBinding(t *testing.T) {
+	m := New(Config{})
+	id, answer := newSolvedID(t)
+
+	if !m.Verify(postForm(id, answer)) {
+		t.Error("Verify rejected the correct answer")
+	}
+}
+
+func TestMiddleware_Verify_WrongAnswer(t *testing.T) {
+	m := New(Config{})
+	id, _ := newSolvedID(t)
+
+	if m.Verify(postForm(id, "wrong")) {
+		t.Error("Verify accepted a wrong answer")
+	}
+}
+
+func TestMiddleware_Verify_MissingFields(t *testing.T) {
+	m := New(Config{})
+	if m.Verify(postForm("", "")) {
+		t.Error("Verify accepted a request with no id or solution")
+	}
+}
+
+func TestMiddleware_Verify_SessionBinding(t *testing.T) {
+	sessionOf := func(r *http.Request) string { return r.Header.Get("X-Session") }
+	m := New(Config{SessionID: sessionOf})
+	id, answer := newSolvedID(t)
+
+	mintReq := httptest.NewRequest("GET", "http://example.com/", nil)
+	mintReq.Header.Set("X-Session", "session-a")
+	m.bind(id, sessionOf(mintReq))
+
+	wrongSession := postForm(id, answer)
+	wrongSession.Header.Set("X-Session", "session-b")
+	if m.Verify(wrongSession) {
+		t.Error("Verify accepted a solution submitted from a different session than the one the id was minted for")
+	}
+}
+
+// TestMiddleware_Verify_WrongSessionDoesNotConsumeBinding guards against a
+// DoS where anyone who can see a rendered form's captcha_id (i.e. anyone)
+// POSTs it with a wrong or garbage session, destroying the legitimate
+// owner's binding so their own, correct, same-session submission then
+// fails too.
+func TestMiddleware_Verify_WrongSessionDoesNotConsumeBinding(t *testing.T) {
+	sessionOf := func(r *http.Request) string { return r.Header.Get("X-Session") }
+	m := New(Config{SessionID: sessionOf})
+	id, answer := newSolvedID(t)
+	m.bind(id, "session-a")
+
+	attack := postForm(id, answer)
+	attack.Header.Set("X-Session", "session-b")
+	if m.Verify(attack) {
+		t.Fatal("Verify accepted a solution submitted from a different session")
+	}
+
+	legit := postForm(id, answer)
+	legit.Header.Set("X-Session", "session-a")
+	if !m.Verify(legit) {
+		t.Error("the legitimate session's correct submission was rejected after an unrelated wrong-session attempt; checkSession must not consume the binding on a mismatch")
+	}
+}
+
+func TestMiddleware_Verify_SessionBindingSingleUse(t *testing.T) {
+	sessionOf := func(r *http.Request) string { return r.Header.Get("X-Session") }
+	m := New(Config{SessionID: sessionOf})
+	id, answer := newSolvedID(t)
+	m.bind(id, "session-a")
+
+	req := func() *http.Request {
+		r := postForm(id, answer)
+		r.Header.Set("X-Session", "session-a")
+		return r
+	}
+	if !m.Verify(req()) {
+		t.Fatal("first Verify with a matching session binding should succeed")
+	}
+	if m.Verify(req()) {
+		t.Error("second Verify for the same id succeeded; session binding should be consumed")
+	}
+}
+
+func TestMiddleware_SessionBindingExpires(t *testing.T) {
+	m := New(Config{SessionID: func(r *http.Request) string { return "session-a" }})
+	id, _ := newSolvedID(t)
+
+	m.mu.Lock()
+	b := m.session[id]
+	b.expiresAt = time.Now().Add(-time.Second)
+	m.session[id] = b
+	m.mu.Unlock()
+
+	if m.checkSession(id, "session-a") {
+		t.Error("checkSession accepted a binding past its expiresAt")
+	}
+}
+
+func TestMiddleware_SweepsExpiredSessions(t *testing.T) {
+	m := New(Config{})
+	staleID := "stale-test-id"
+	m.mu.Lock()
+	m.session[staleID] = sessionBinding{sessionID: "x", expiresAt: time.Now().Add(-time.Minute)}
+	m.mu.Unlock()
+
+	m.bind("fresh-id", "y") // any bind call should sweep staleID away
+
+	m.mu.Lock()
+	_, stillThere := m.session[staleID]
+	m.mu.Unlock()
+	if stillThere {
+		t.Error("bind didn't sweep an already-expired session binding; m.session leaks unbounded")
+	}
+}
@@ -12,10 +12,34 @@ import (
 	"strings"
 )
 
+// defaultEncoders maps the extensions captchaHandler recognizes to the
+// Encoder that renders them, absent an override in ServerOptions.Encoders.
+func defaultEncoders() map[string]Encoder {
+	return map[string]Encoder{
+		".png": PNGEncoder{CompressionLevel: png.BestSpeed},
+		".jpg": JPEGEncoder{},
+		".gif": GIFEncoder{},
+	}
+}
+
 type captchaHandler struct {
 	imgWidth  int
 	imgHeight int
 	opts      *DistortionOpts
+	encoders  map[string]Encoder
+}
+
+// ServerOptions configures the handler returned by ServerWithOptions. The
+// zero value serves PNG images only, equivalent to calling Server directly.
+type ServerOptions struct {
+	ImageWidth     int
+	ImageHeight    int
+	DistortionOpts *DistortionOpts
+
+	// Encoders overrides the Encoder used for one or more of ".png",
+	// ".jpg", and ".gif". Extensions not present in the map fall back to
+	// defaultEncoders; a nil map uses defaultEncoders for all three.
+	Encoders map[string]Encoder
 }
 
 // Server returns a handler that serves HTTP requests with captcha images.
@@ -29,14 +53,30 @@ type captchaHandler struct {
 // random number to make browsers refetch an image instead of loading it from
 // cache).
 func Server(imgWidth, imgHeight int, opts *DistortionOpts) http.Handler {
-	return &captchaHandler{imgWidth, imgHeight, opts}
+	return &captchaHandler{imgWidth: imgWidth, imgHeight: imgHeight, opts: opts, encoders: defaultEncoders()}
+}
+
+// ServerWithOptions is like Server but also allows overriding the image
+// Encoder used for ".png", ".jpg", and ".gif".
+func ServerWithOptions(opts ServerOptions) http.Handler {
+	encoders := defaultEncoders()
+	for ext, enc := range opts.Encoders {
+		encoders[ext] = enc
+	}
+	return &captchaHandler{
+		imgWidth:  opts.ImageWidth,
+		imgHeight: opts.ImageHeight,
+		opts:      opts.DistortionOpts,
+		encoders:  encoders,
+	}
 }
 
 func (h *captchaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	dir, file := path.Split(r.URL.Path)
 	ext := path.Ext(file)
 	id := strings.TrimSuffix(file, ext)
-	if ext != ".png" || id == "" {
+	enc, isImage := h.encoder(ext)
+	if id == "" || !isImage {
 		http.NotFound(w, r)
 		return
 	}
@@ -56,6 +96,24 @@ func (h *captchaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isDownload := path.Base(dir) == "download"
+
+	h.serveImage(w, id, digits, enc, isDownload)
+}
+
+// encoder returns the Encoder registered for ext and whether one was
+// found; the caller still needs to fall back to defaultEncoders for a
+// zero-value captchaHandler (e.g. in tests that construct one by hand).
+func (h *captchaHandler) encoder(ext string) (Encoder, bool) {
+	encoders := h.encoders
+	if encoders == nil {
+		encoders = defaultEncoders()
+	}
+	enc, ok := encoders[ext]
+	return enc, ok
+}
+
+func (h *captchaHandler) serveImage(w http.ResponseWriter, id string, digits []byte, enc Encoder, isDownload bool) {
 	img := NewImage(id, digits, h.imgWidth, h.imgHeight, h.opts)
 	if img == nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError),
@@ -63,17 +121,13 @@ func (h *captchaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	switch path.Base(dir) {
-	case "download":
+	if isDownload {
 		w.Header().Set("Content-Type", "application/octet-stream")
-	default:
-		w.Header().Set("Content-Type", "image/png")
+	} else {
+		w.Header().Set("Content-Type", enc.ContentType())
 	}
 
-	enc := png.Encoder{
-		CompressionLevel: png.BestSpeed,
-	}
-	if err := enc.Encode(w, img.Paletted); err != nil {
+	if err := enc.Encode(w, img); err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError),
 			http.StatusInternalServerError)
 	}
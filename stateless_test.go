@@ -0,0 +1,193 @@
+// Copyright 2019 Jonathan Chappelow. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package captcha
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptcha_TokenRoundTrip(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, dataURL, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+
+	if !strings.HasPrefix(dataURL, "data:image/png;base64,") {
+		t.Fatalf("NewChallenge returned a data URL with an unexpected prefix")
+	}
+
+	token := c.Token(id, answer)
+	if !c.VerifyAnswer(id, token, answer, nil) {
+		t.Error("VerifyAnswer rejected the correct answer for its own token")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_WrongAnswer(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	if c.VerifyAnswer(id, token, answer+"x", nil) {
+		t.Error("VerifyAnswer accepted a wrong answer")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_WrongID(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	if c.VerifyAnswer(id+"x", token, answer, nil) {
+		t.Error("VerifyAnswer accepted a token issued for a different id")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_TamperedToken(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		t.Fatalf("unexpected token shape: %q", token)
+	}
+	tampered := parts[0] + "x." + parts[1]
+	if c.VerifyAnswer(id, tampered, answer, nil) {
+		t.Error("VerifyAnswer accepted a token with a tampered payload")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_WrongKey(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	other := &Captcha{Key: []byte("a-different-key")}
+	if other.VerifyAnswer(id, token, answer, nil) {
+		t.Error("VerifyAnswer accepted a token signed with a different key")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_Expired(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key"), TokenTTL: -time.Second}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	if c.VerifyAnswer(id, token, answer, nil) {
+		t.Error("VerifyAnswer accepted an already-expired token")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_MalformedToken(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+
+	for _, tok := range []string{"", "no-dot-in-here", "not-base64!!.also-not-base64!!"} {
+		if c.VerifyAnswer(id, tok, answer, nil) {
+			t.Errorf("VerifyAnswer accepted malformed token %q", tok)
+		}
+	}
+}
+
+// TestCaptcha_Token_AnswerHashIsKeyed guards against Token embedding a bare
+// sha256.Sum256(answer): for a default numeric challenge, that would let
+// anyone holding the token brute-force every 6-digit answer offline in
+// microseconds without ever knowing Key, defeating the captcha entirely.
+func TestCaptcha_Token_AnswerHashIsKeyed(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		t.Fatalf("unexpected token shape: %q", token)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	fields := strings.SplitN(string(payload), "|", 3)
+	if len(fields) != 3 {
+		t.Fatalf("unexpected payload shape: %q", payload)
+	}
+	answerHash, err := base64.RawURLEncoding.DecodeString(fields[1])
+	if err != nil {
+		t.Fatalf("decoding answer hash: %v", err)
+	}
+
+	for n := 0; n < 1000000; n++ {
+		guess := strconv.Itoa(n)
+		for len(guess) < len(answer) {
+			guess = "0" + guess
+		}
+		sum := sha256.Sum256([]byte(guess))
+		if string(sum[:]) == string(answerHash) {
+			t.Fatalf("brute-forced answer %q against an unkeyed sha256 of the token's hash field; answer hash must be HMAC-keyed", guess)
+		}
+	}
+}
+
+func TestCaptcha_NewChallenge_DoesNotRegisterGlobalChallenge(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	d := &MathDriver{}
+	id, _, _, err := c.NewChallenge(ChallengeOptions{Driver: d})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+
+	if _, ok := VerifyDriverAnswer(id, "anything"); ok {
+		t.Error("NewChallenge with a StatelessGenerator Driver registered an id in the package-level challenges map")
+	}
+}
+
+func TestCaptcha_VerifyAnswer_DriverNormalization(t *testing.T) {
+	c := &Captcha{Key: []byte("test-key")}
+	d := &MathDriver{}
+	id, answer, _, err := c.NewChallenge(ChallengeOptions{Driver: d})
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	token := c.Token(id, answer)
+
+	padded := "0" + answer
+	if strings.HasPrefix(answer, "-") {
+		padded = "-0" + answer[1:]
+	}
+	for _, submitted := range []string{answer, " " + answer + " ", padded} {
+		if !c.VerifyAnswer(id, token, submitted, d) {
+			t.Errorf("VerifyAnswer(%q) = false, want true (answer %q)", submitted, answer)
+		}
+	}
+	if c.VerifyAnswer(id, token, "not a number", d) {
+		t.Error("VerifyAnswer accepted a non-numeric submission against a MathDriver token")
+	}
+}